@@ -0,0 +1,76 @@
+/*
+The bench tool demonstrates the throughput tradeoff between msg.Object's
+default synchronous writes and Async mode by logging a batch of messages
+to a deliberately slow writer both ways and printing how long each took.
+
+Usage:
+      $ go run msg/bench/bench.go
+      $ go run msg/bench/bench.go -n 5000 -latency 2ms
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jlinoff/go/msg"
+)
+
+// slowWriter simulates a sink with per-write latency, e.g. a spinning
+// disk or a remote collector over a slow link.
+type slowWriter struct {
+	latency time.Duration
+	n       int
+	bytes   int
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(w.latency)
+	w.n++
+	w.bytes += len(p)
+	return len(p), nil
+}
+
+func run(count int, latency time.Duration, async bool, bufSize int, flushInterval time.Duration) (time.Duration, *slowWriter) {
+	sw := &slowWriter{latency: latency}
+	log, err := msg.NewMsg("bench", "", "", []io.Writer{sw})
+	if err != nil {
+		panic(err)
+	}
+
+	if async {
+		log.Async(bufSize, flushInterval)
+	}
+
+	start := time.Now()
+	for i := 0; i < count; i++ {
+		log.Info("message %v", i)
+	}
+	log.Flush()
+	elapsed := time.Since(start)
+
+	if async {
+		log.Close()
+	}
+	return elapsed, sw
+}
+
+func main() {
+	n := flag.Int("n", 2000, "number of messages to log")
+	latency := flag.Duration("latency", time.Millisecond, "simulated per-write latency of the sink")
+	bufSize := flag.Int("bufsize", 1024, "Async channel capacity")
+	flushInterval := flag.Duration("flush", 100*time.Millisecond, "Async flush interval")
+	flag.Parse()
+
+	syncElapsed, syncW := run(*n, *latency, false, *bufSize, *flushInterval)
+	fmt.Printf("sync:  %v writes, %v bytes, %v (%.0f msg/s)\n",
+		syncW.n, syncW.bytes, syncElapsed, float64(*n)/syncElapsed.Seconds())
+
+	asyncElapsed, asyncW := run(*n, *latency, true, *bufSize, *flushInterval)
+	fmt.Printf("async: %v writes, %v bytes, %v (%.0f msg/s)\n",
+		asyncW.n, asyncW.bytes, asyncElapsed, float64(*n)/asyncElapsed.Seconds())
+
+	fmt.Printf("speedup: %.1fx\n", syncElapsed.Seconds()/asyncElapsed.Seconds())
+}
@@ -62,12 +62,17 @@ package msg
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path"
+	"regexp"
 	"runtime"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -88,6 +93,135 @@ type Interface interface {
 	Printf(f string, a ...interface{})
 }
 
+// Level is the severity of a message, ordered so that DEBUG < INFO <
+// WARNING < ERROR < FATAL. It backs Object.MinLevel.
+type Level int
+
+// The recognized severities, from least to most severe.
+const (
+	DEBUG Level = iota
+	INFO
+	WARNING
+	ERROR
+	FATAL
+)
+
+// levelOf maps the message type string used throughout PrintMsg/output
+// ("DEBUG", "INFO", ...) to its Level. Unrecognized types are treated as
+// INFO so an unexpected caller does not get silently dropped.
+func levelOf(t string) Level {
+	switch t {
+	case "DEBUG":
+		return DEBUG
+	case "WARNING":
+		return WARNING
+	case "ERROR":
+		return ERROR
+	case "FATAL":
+		return FATAL
+	default:
+		return INFO
+	}
+}
+
+/*
+Filter inspects (and may redact or suppress) a record before it reaches
+any writer. level, file and msg describe the record as output() resolved
+it; kv is the merged key/value context (see With(), the "w" variants).
+
+A Filter returns drop=true to suppress the record entirely, otherwise
+newMsg/newKV replace msg/kv for the rest of the pipeline and for the
+final rendering - a Filter that wants to leave them alone should just
+return its msg/kv arguments unchanged.
+*/
+type Filter func(level Level, file string, msg string, kv []interface{}) (drop bool, newMsg string, newKV []interface{})
+
+/*
+AddFilter appends f to the filter pipeline. Filters run in the order
+added, each seeing the (possibly already redacted) output of the last.
+
+Example:
+      log.AddFilter(msg.FilterKeys("password", "token"))
+      log.AddFilter(msg.FilterMinLevel(msg.WARNING))
+*/
+func (o *Object) AddFilter(f Filter) {
+	o.filters = append(o.filters, f)
+}
+
+// FilterMinLevel drops any record below level l. Unlike MinLevel (which
+// never suppresses ERROR, see output) this is an unconditional floor,
+// useful for scoping a filter pipeline stage independently of the
+// logger's own threshold.
+func FilterMinLevel(l Level) Filter {
+	return func(level Level, file string, msg string, kv []interface{}) (bool, string, []interface{}) {
+		return level < l, msg, kv
+	}
+}
+
+// FilterKeys replaces the value of any kv pair whose key matches one of
+// keys with "***". Comparison is exact (case sensitive).
+func FilterKeys(keys ...string) Filter {
+	redact := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		redact[k] = true
+	}
+	return func(level Level, file string, msg string, kv []interface{}) (bool, string, []interface{}) {
+		if len(kv) == 0 {
+			return false, msg, kv
+		}
+		out := append([]interface{}{}, kv...)
+		for i := 0; i+1 < len(out); i += 2 {
+			if k, ok := out[i].(string); ok && redact[k] {
+				out[i+1] = "***"
+			}
+		}
+		return false, msg, out
+	}
+}
+
+// FilterValues replaces any kv value equal to one of values (after
+// fmt.Sprintf("%v", ...) conversion) with "***".
+func FilterValues(values ...string) Filter {
+	redact := make(map[string]bool, len(values))
+	for _, v := range values {
+		redact[v] = true
+	}
+	return func(level Level, file string, msg string, kv []interface{}) (bool, string, []interface{}) {
+		if len(kv) == 0 {
+			return false, msg, kv
+		}
+		out := append([]interface{}{}, kv...)
+		for i := 1; i < len(out); i += 2 {
+			if redact[fmt.Sprintf("%v", out[i])] {
+				out[i] = "***"
+			}
+		}
+		return false, msg, out
+	}
+}
+
+// FilterRegex replaces every match of re in the rendered message with
+// "***", e.g. to scrub credit card numbers or API keys from free text
+// that could not be kept out of a %v argument.
+func FilterRegex(re *regexp.Regexp) Filter {
+	return func(level Level, file string, msg string, kv []interface{}) (bool, string, []interface{}) {
+		return false, re.ReplaceAllString(msg, "***"), kv
+	}
+}
+
+// Encoder selects how a message is rendered before it is handed to the
+// writers.
+type Encoder int
+
+// The available encoders. TextEncoder renders the message using Format
+// (the default). JSONEncoder writes one JSON object per line with the
+// fields "time", "level", "caller" and "msg" plus any key/value pairs
+// accumulated via With()/the "w" variants (Debugw, Infow, ...).
+const (
+	TextEncoder Encoder = iota
+	JSONEncoder
+)
+
 // Object defines the logger.
 type Object struct {
 	// Name is the package name. It is accessed in the format string by %pkg.
@@ -95,16 +229,48 @@ type Object struct {
 
 	// DebugEnabled enables debug messages if true.
 	// It is true by default.
+	//
+	// Deprecated: set MinLevel instead. DebugEnabled is still consulted
+	// (it must be true for a Debug message to print at all) but MinLevel
+	// is the recommended single threshold going forward.
 	DebugEnabled bool
 
 	// InfoEnabled enables info messages if true.
 	// It is true by default.
+	//
+	// Deprecated: set MinLevel instead, see DebugEnabled.
 	InfoEnabled bool
 
 	// WarningEnabled enables warning messages if true.
 	// It is true by default.
+	//
+	// Deprecated: set MinLevel instead, see DebugEnabled.
 	WarningEnabled bool
 
+	// MinLevel is the minimum Level that will be printed. It supersedes
+	// DebugEnabled/InfoEnabled/WarningEnabled: a message is printed only if
+	// its Level is >= MinLevel AND the corresponding deprecated boolean (if
+	// any) is still true. The one exception is ERROR: MinLevel never
+	// suppresses ERROR messages (see output). The zero value, DEBUG,
+	// prints everything, which matches the package default before
+	// MinLevel existed.
+	MinLevel Level
+
+	// Verbosity is the global V-level threshold consulted by V(), modeled
+	// on glog's -v flag. The default, 0, means V(0) is the only level
+	// enabled unless VModule grants a higher level to the caller's file.
+	Verbosity int
+
+	// VModule is a glog-style per-file/module verbosity override spec, a
+	// comma separated list of "pattern=level" pairs, e.g.
+	// "parser=3,run/*=1". pattern is matched against the caller's base
+	// file name (e.g. "parser") and, if it contains a '/', against
+	// "dir/file" (e.g. "run/run"). '*' and '?' are supported as in
+	// path.Match. It is parsed lazily by V() the first time a given spec
+	// string is seen and the parse is cached, so changing VModule at
+	// runtime takes effect on the next call. See SetVModuleFromEnv.
+	VModule string
+
 	// ErrorExitCode is the exit code to use for the Error function.
 	// The default is 1.
 	ErrorExitCode int
@@ -120,6 +286,8 @@ type Object struct {
 
 	// Format is the template for the output. It has the following specifiers.
 	//
+	//   %fields is the accumulated key/value context (see With(), Debugw(), ...)
+	//           rendered as "key=value key=value ...", quoting values with spaces
 	//   %file is the caller file name
 	//   %func is the function name
 	//   %line is the line number
@@ -141,11 +309,51 @@ type Object struct {
 	//   `%(-27)time %(-7)type %file %line - %msg`
 	Format string
 
+	// Encoder selects how messages are rendered. The default, TextEncoder,
+	// uses Format. JSONEncoder writes one JSON object per line instead and
+	// ignores Format.
+	Encoder Encoder
+
 	// outputFormat created by NewMsg and used to generate a message.
 	outputFormat string
 
 	// outputFlds created by NewMsg and used to specify the fields.
 	outputFlds []string
+
+	// kv holds the persistent key/value context accumulated via With().
+	// It is merged with the keyvals passed to the "w" variants
+	// (Debugw, Infow, Warnw, Errw) and rendered by the %fields specifier.
+	kv []interface{}
+
+	// filters is the pipeline installed by AddFilter, run in order inside
+	// output() before any writer sees the record.
+	filters []Filter
+
+	// mu guards the fmt.Fprintf calls in output() so that Writers backed
+	// by sinks such as msg/sinks (files, syslog, TCP) can be written from
+	// multiple goroutines safely. It is a pointer so that Object, which is
+	// normally passed by value, can still be copied (e.g. by With())
+	// without copying lock state.
+	mu *sync.Mutex
+
+	// OverflowPolicy controls what Async does when a writer's buffer is
+	// full. It is only consulted once Async has been called; it is
+	// ignored in the default synchronous mode. The zero value, Block,
+	// matches synchronous behavior (a slow writer slows down callers)
+	// most closely.
+	OverflowPolicy OverflowPolicy
+
+	// asyncRef holds the state installed by Async(), or nil in it in the
+	// default synchronous mode. Like mu it is allocated once by the
+	// constructor and shared by every copy of Object (e.g. via With()),
+	// so Async/Close calls on one copy are visible to all of them. It is
+	// an atomic.Pointer, rather than a plain *asyncState guarded by mu,
+	// because output() reads it on every call and Close() may swap it to
+	// nil concurrently from another goroutine sharing this Object - a
+	// plain pointer field read/written without synchronization on both
+	// sides is a data race even though pointer assignment itself is
+	// word-sized.
+	asyncRef *atomic.Pointer[asyncState]
 }
 
 // NewMsg makes a message object.
@@ -160,6 +368,8 @@ func NewMsg(n string, f string, t string, w []io.Writer) (obj *Object, err error
 	obj.InfoEnabled = true
 	obj.WarningEnabled = true
 	obj.ErrorExitCode = 1
+	obj.mu = new(sync.Mutex)
+	obj.asyncRef = new(atomic.Pointer[asyncState])
 
 	if len(w) == 0 {
 		obj.Writers = append(obj.Writers, os.Stdout)
@@ -189,6 +399,133 @@ func NewMsg(n string, f string, t string, w []io.Writer) (obj *Object, err error
 	return
 }
 
+/*
+With returns a child logger that carries a persistent set of key/value
+pairs. Every message logged through the child (including further
+descendants created by With) renders those pairs via the %fields
+specifier and, for JSONEncoder, as merged top level fields.
+
+Example:
+      reqlog := log.With("reqID", id, "user", user)
+      reqlog.Infow("handled request", "status", 200)
+*/
+func (o Object) With(keyvals ...interface{}) *Object {
+	n := o
+	n.kv = append(append([]interface{}{}, o.kv...), keyvals...)
+	return &n
+}
+
+/*
+renderFields renders a list of alternating key, value, key, value, ...
+pairs as "key=value key=value ..." text, quoting any value containing
+whitespace. An odd trailing key with no value is rendered as "key=?".
+*/
+func renderFields(kv []interface{}) string {
+	if len(kv) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(kv)/2+1)
+	for i := 0; i < len(kv); i += 2 {
+		k := fmt.Sprintf("%v", kv[i])
+		v := "?"
+		if i+1 < len(kv) {
+			v = fmt.Sprintf("%v", kv[i+1])
+		}
+		if strings.ContainsAny(v, " \t\n") {
+			v = strconv.Quote(v)
+		}
+		parts = append(parts, k+"="+v)
+	}
+	return strings.Join(parts, " ")
+}
+
+// vmoduleCache memoizes the parse of a VModule spec string so that V()
+// does not re-split/re-parse it on every call.
+var vmoduleCache sync.Map // spec string -> map[string]int
+
+// parseVModule parses a glog-style "pattern=level,pattern=level" spec.
+// Malformed entries are ignored.
+func parseVModule(spec string) map[string]int {
+	m := map[string]int{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		lvl, err := strconv.Atoi(strings.TrimSpace(kv[1]))
+		if err != nil {
+			continue
+		}
+		m[strings.TrimSpace(kv[0])] = lvl
+	}
+	return m
+}
+
+// vmoduleLevelFor looks up the vmodule override for a caller, identified
+// by its bare file name (e.g. "parser") and its "dir/file" form (e.g.
+// "run/run"). It returns the matching level and true if spec grants an
+// override, or (0, false) if nothing matches.
+func vmoduleLevelFor(spec string, base string, dirBase string) (int, bool) {
+	if spec == "" {
+		return 0, false
+	}
+	var vmod map[string]int
+	if v, ok := vmoduleCache.Load(spec); ok {
+		vmod = v.(map[string]int)
+	} else {
+		vmod = parseVModule(spec)
+		vmoduleCache.Store(spec, vmod)
+	}
+	for pattern, lvl := range vmod {
+		if ok, _ := path.Match(pattern, dirBase); ok {
+			return lvl, true
+		}
+		if ok, _ := path.Match(pattern, base); ok {
+			return lvl, true
+		}
+	}
+	return 0, false
+}
+
+/*
+V reports whether verbosity level "level" is enabled for the calling
+file, glog style: it is enabled if level <= Verbosity, unless VModule
+grants a different level to the caller's file/module, in which case that
+level is used instead.
+
+Example:
+      if log.V(2) {
+          log.Info("expensive diagnostic: %v", computeDiagnostic())
+      }
+*/
+func (o Object) V(level int) bool {
+	threshold := o.Verbosity
+	if _, fname, _, ok := runtime.Caller(1); ok {
+		base := strings.TrimSuffix(path.Base(fname), ".go")
+		dirBase := path.Base(path.Dir(fname)) + "/" + base
+		if lvl, found := vmoduleLevelFor(o.VModule, base, dirBase); found {
+			threshold = lvl
+		}
+	}
+	return level <= threshold
+}
+
+/*
+SetVModuleFromEnv sets VModule from the named environment variable, e.g.
+      log.SetVModuleFromEnv("MYAPP_VMODULE")
+lets users tune per-file verbosity without recompiling. It is a no-op if
+the variable is unset.
+*/
+func (o *Object) SetVModuleFromEnv(envVar string) {
+	if v, ok := os.LookupEnv(envVar); ok {
+		o.VModule = v
+	}
+}
+
 /*
 Debug prints a debug message obtaining the callers filename, function and
 line number.
@@ -216,6 +553,20 @@ func (o Object) DebugWithLevel(l int, f string, a ...interface{}) {
 	}
 }
 
+/*
+Debugw prints a structured debug message: msg followed by alternating
+key/value pairs merged with any context set by With(). The pairs are
+rendered by the %fields specifier (or as merged fields for JSONEncoder).
+
+Example:
+      msg.Debugw("starting worker", "id", id, "queue", q)
+*/
+func (o Object) Debugw(msg string, keyvals ...interface{}) {
+	if o.DebugEnabled {
+		o.printMsgKV("DEBUG", 2, msg, keyvals...)
+	}
+}
+
 /*
 Info prints an info message obtaining the callers filename, function and
 line number.
@@ -243,6 +594,19 @@ func (o Object) InfoWithLevel(l int, f string, a ...interface{}) {
 	}
 }
 
+/*
+Infow prints a structured info message: msg followed by alternating
+key/value pairs merged with any context set by With().
+
+Example:
+      msg.Infow("handled request", "status", 200, "path", p)
+*/
+func (o Object) Infow(msg string, keyvals ...interface{}) {
+	if o.InfoEnabled {
+		o.printMsgKV("INFO", 2, msg, keyvals...)
+	}
+}
+
 /*
 Warn prints a warning message obtaining the callers filename, function and
 line number.
@@ -270,6 +634,19 @@ func (o Object) WarnWithLevel(l int, f string, a ...interface{}) {
 	}
 }
 
+/*
+Warnw prints a structured warning message: msg followed by alternating
+key/value pairs merged with any context set by With().
+
+Example:
+      msg.Warnw("retrying", "attempt", n, "err", e)
+*/
+func (o Object) Warnw(msg string, keyvals ...interface{}) {
+	if o.WarningEnabled {
+		o.printMsgKV("WARNING", 2, msg, keyvals...)
+	}
+}
+
 /*
 Err prints an error message obtaining the callers filename, function and
 line number and exits. It cannot be disabled.
@@ -295,6 +672,19 @@ func (o Object) ErrWithLevel(l int, f string, a ...interface{}) {
 	os.Exit(o.ErrorExitCode)
 }
 
+/*
+Errw prints a structured error message: msg followed by alternating
+key/value pairs merged with any context set by With(), then exits.
+It cannot be disabled.
+
+Example:
+      msg.Errw("fatal config error", "file", path, "err", e)
+*/
+func (o Object) Errw(msg string, keyvals ...interface{}) {
+	o.printMsgKV("ERROR", 2, msg, keyvals...)
+	os.Exit(o.ErrorExitCode)
+}
+
 /*
 ErrNoExit prints an error message obtaining the callers filename, function and
 line number. It does not exit and cannot be disabled.
@@ -345,38 +735,108 @@ formatted messages and normally would not be called directly.
       a - argument list
 */
 func (o Object) PrintMsg(t string, l int, f string, a ...interface{}) {
-	pc, fname, lineno, _ := runtime.Caller(l)
+	o.output(t, l, o.kv, fmt.Sprintf(f, a...))
+}
+
+/*
+printMsgKV is the basis of the structured "w" printers (Debugw, Infow,
+Warnw, Errw). Unlike PrintMsg, msg is rendered verbatim (it is not run
+through fmt.Sprintf as a format string) and keyvals is merged with any
+context accumulated via With().
+
+      t       - is the type, normally one of DEBUG, INFO, WARNING or ERROR
+      l       - is the caller level, see PrintMsg
+      msg     - the message text, printed as is
+      keyvals - alternating key, value, key, value, ... pairs
+*/
+func (o Object) printMsgKV(t string, l int, msg string, keyvals ...interface{}) {
+	kv := append(append([]interface{}{}, o.kv...), keyvals...)
+	o.output(t, l, kv, msg)
+}
+
+/*
+output resolves the caller, renders the message using either the
+TextEncoder (Format/outputFormat) or the JSONEncoder and writes the
+result to every writer. It is shared by PrintMsg and printMsgKV.
+*/
+func (o Object) output(t string, l int, kv []interface{}, msg string) {
+	pc, rawfname, lineno, _ := runtime.Caller(l + 1)
 	fct := runtime.FuncForPC(pc).Name()
-	fname = path.Base(fname[0 : len(fname)-3]) // strip off ".go"
+	fname := path.Base(rawfname[0 : len(rawfname)-3]) // strip off ".go"
+
+	lvl := levelOf(t)
+
+	// MinLevel supersedes the deprecated DebugEnabled/InfoEnabled/
+	// WarningEnabled booleans (already checked by the callers above).
+	// VModule is scoped to V() (see its doc comment) and plays no part in
+	// this gate. ERROR is never suppressed: Err/ErrWithLevel/Errw/
+	// ErrNoExit/ErrNoExitWithLevel all document that they "cannot be
+	// disabled", and the exiting variants would otherwise os.Exit with
+	// zero diagnostic output if MinLevel were set above ERROR.
+	if lvl < o.MinLevel && lvl != ERROR {
+		return
+	}
+
+	// Run the filter pipeline. Filters may redact the message/kv or drop
+	// the record entirely; they run before any writer sees the record.
+	for _, filt := range o.filters {
+		var drop bool
+		drop, msg, kv = filt(lvl, fname, msg, kv)
+		if drop {
+			return
+		}
+	}
 
 	// The variables map for the format string.
 	m := map[string]string{
-		"file": fname,
-		"func": fct,
-		"line": strconv.Itoa(lineno),
-		"msg":  fmt.Sprintf(f, a...),
-		"pkg":  o.Name,
-		"time": time.Now().Truncate(time.Millisecond).Format(o.TimeFormat),
-		"utc":  time.Now().UTC().Truncate(time.Millisecond).Format(o.TimeFormat),
-		"type": t,
-	}
-
-	// Collect the field values.
-	var flds []interface{}
-	for _, k := range o.outputFlds {
-		if v, ok := m[k]; ok {
-			flds = append(flds, v)
-		} else {
-			// This is, essentially, an assert. It should never happen.
-			fmt.Fprintf(os.Stderr, "ERROR: unexpected condition, invalid specification id '%v'\n", k)
-			os.Exit(1)
+		"file":   fname,
+		"func":   fct,
+		"line":   strconv.Itoa(lineno),
+		"msg":    msg,
+		"pkg":    o.Name,
+		"time":   time.Now().Truncate(time.Millisecond).Format(o.TimeFormat),
+		"utc":    time.Now().UTC().Truncate(time.Millisecond).Format(o.TimeFormat),
+		"type":   t,
+		"fields": renderFields(kv),
+	}
+
+	var s string
+	if o.Encoder == JSONEncoder {
+		s = o.encodeJSON(t, m, kv) + "\n"
+	} else {
+		// Collect the field values.
+		var flds []interface{}
+		for _, k := range o.outputFlds {
+			if v, ok := m[k]; ok {
+				flds = append(flds, v)
+			} else {
+				// This is, essentially, an assert. It should never happen.
+				fmt.Fprintf(os.Stderr, "ERROR: unexpected condition, invalid specification id '%v'\n", k)
+				os.Exit(1)
+			}
 		}
+		s = fmt.Sprintf(o.outputFormat, flds...) + "\n"
 	}
 
-	// Create the formatted output string.
-	s := fmt.Sprintf(o.outputFormat, flds...) + "\n"
+	// In Async mode, hand the fully rendered record to each writer's
+	// background worker instead of writing here synchronously. asyncRef
+	// is loaded atomically since Close/Async may swap it out from
+	// another goroutine sharing this *Object concurrently with output().
+	if o.asyncRef != nil {
+		if async := o.asyncRef.Load(); async != nil {
+			async.enqueue(s)
+			return
+		}
+	}
 
-	// Output it for each writer.
+	// Output it for each writer. The mutex keeps concurrent callers from
+	// interleaving writes to the same sink (important for file/syslog/TCP
+	// sinks from the msg/sinks package, which may be written from several
+	// goroutines).
+	if o.mu != nil {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+	}
 	for _, w := range o.Writers {
 		_, err := fmt.Fprintf(w, s)
 		if err != nil {
@@ -391,6 +851,340 @@ FATAL: fmt.Fprintf() failed for writer %v
 	}
 }
 
+/*
+Close stops Async mode, if running (draining every writer's buffered
+records first), then closes any Writers that implement io.Closer (for
+example the sinks in msg/sinks). It returns the first error encountered,
+if any, but always attempts to close every closer.
+*/
+func (o *Object) Close() error {
+	if o.asyncRef != nil {
+		if async := o.asyncRef.Swap(nil); async != nil {
+			async.stop()
+		}
+	}
+
+	var firstErr error
+	for _, w := range o.Writers {
+		if c, ok := w.(io.Closer); ok {
+			if err := c.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// OverflowPolicy selects what an Async writer does when its buffer is
+// full and a new record arrives.
+type OverflowPolicy int
+
+const (
+	// Block makes the caller wait for room in the buffer, same as the
+	// synchronous default but for just that one writer.
+	Block OverflowPolicy = iota
+
+	// DropNewest discards the incoming record and keeps whatever is
+	// already buffered.
+	DropNewest
+
+	// DropOldest discards the oldest buffered record to make room for the
+	// incoming one.
+	DropOldest
+)
+
+// Stats reports what Async's writers have done since Async was called.
+type Stats struct {
+	// Dropped is how many records OverflowPolicy DropNewest/DropOldest
+	// has discarded across all writers.
+	Dropped uint64
+
+	// WriteErrors is how many writes to an underlying Writer have failed.
+	// Async cannot propagate these synchronously (the caller that logged
+	// the record is long gone), so they are only visible here.
+	WriteErrors uint64
+}
+
+// maxAsyncBatch bounds how much an asyncWorker accumulates before
+// flushing early, so a burst of records does not grow memory unbounded
+// even when flushInterval is long.
+const maxAsyncBatch = 64 * 1024
+
+// asyncState is what Object.asyncRef points to, installed by Async() and torn
+// down by Close(). It is referenced through a pointer so Object, usually
+// passed by value, shares one set of workers across every copy.
+type asyncState struct {
+	policy        OverflowPolicy
+	flushInterval time.Duration
+	workers       []*asyncWorker
+	dropped       uint64 // atomic
+	writeErrors   uint64 // atomic
+
+	// mu is the same lock output()'s synchronous path takes around its
+	// fmt.Fprintf calls (Object.mu). asyncState's own workers take it
+	// around their flush writes too, so a writer is never written to
+	// from both an async worker and a concurrent synchronous caller at
+	// once - which can otherwise happen for a moment while Close() is
+	// tearing an Object's Async mode down (asyncRef is cleared before
+	// stop() has finished draining the workers, so a logging call on
+	// another goroutine can take the now-unlocked synchronous path while
+	// a worker is still mid-flush on the same Writer).
+	mu *sync.Mutex
+
+	// closeMu guards closed against the race between enqueue (reader)
+	// and stop (writer): stop must not close a worker's records channel
+	// while an enqueue call is still sending to it. Every enqueue holds
+	// a read lock for the duration of its send; stop takes the write
+	// lock, which blocks until those sends finish, before closing
+	// anything.
+	closeMu sync.RWMutex
+	closed  bool
+}
+
+// asyncWorker owns one Writer's buffered channel and background
+// goroutine.
+type asyncWorker struct {
+	w       io.Writer
+	records chan string
+	flush   chan chan struct{}
+	done    chan struct{}
+}
+
+func newAsyncState(writers []io.Writer, bufSize int, flushInterval time.Duration, policy OverflowPolicy, mu *sync.Mutex) *asyncState {
+	a := &asyncState{policy: policy, flushInterval: flushInterval, mu: mu}
+	for _, w := range writers {
+		aw := &asyncWorker{
+			w:       w,
+			records: make(chan string, bufSize),
+			flush:   make(chan chan struct{}),
+			done:    make(chan struct{}),
+		}
+		a.workers = append(a.workers, aw)
+		go a.run(aw)
+	}
+	return a
+}
+
+func (a *asyncState) run(aw *asyncWorker) {
+	defer close(aw.done)
+	var buf strings.Builder
+	ticker := time.NewTicker(a.flushInterval)
+	defer ticker.Stop()
+
+	flushBuf := func() {
+		if buf.Len() == 0 {
+			return
+		}
+		if a.mu != nil {
+			a.mu.Lock()
+		}
+		_, err := fmt.Fprint(aw.w, buf.String())
+		if a.mu != nil {
+			a.mu.Unlock()
+		}
+		if err != nil {
+			atomic.AddUint64(&a.writeErrors, 1)
+		}
+		buf.Reset()
+	}
+
+	for {
+		select {
+		case rec, ok := <-aw.records:
+			if !ok {
+				flushBuf()
+				return
+			}
+			buf.WriteString(rec)
+			if buf.Len() >= maxAsyncBatch {
+				flushBuf()
+			}
+		case <-ticker.C:
+			flushBuf()
+		case ack := <-aw.flush:
+			// Drain whatever is already queued before reporting flushed.
+			for drained := true; drained; {
+				select {
+				case rec, ok := <-aw.records:
+					if !ok {
+						drained = false
+						break
+					}
+					buf.WriteString(rec)
+				default:
+					drained = false
+				}
+			}
+			flushBuf()
+			ack <- struct{}{}
+		}
+	}
+}
+
+// enqueue hands s to every writer, applying the configured
+// OverflowPolicy when a writer's buffer is full. It is a no-op once stop
+// has been called, so a goroutine still logging through an Object that
+// another goroutine just Close()'d drops the record instead of sending
+// on a closed channel.
+func (a *asyncState) enqueue(s string) {
+	a.closeMu.RLock()
+	defer a.closeMu.RUnlock()
+	if a.closed {
+		atomic.AddUint64(&a.dropped, 1)
+		return
+	}
+
+	for _, aw := range a.workers {
+		switch a.policy {
+		case DropNewest:
+			select {
+			case aw.records <- s:
+			default:
+				atomic.AddUint64(&a.dropped, 1)
+			}
+		case DropOldest:
+			for {
+				select {
+				case aw.records <- s:
+				default:
+					select {
+					case <-aw.records:
+						atomic.AddUint64(&a.dropped, 1)
+						continue
+					default:
+					}
+				}
+				break
+			}
+		default: // Block
+			aw.records <- s
+		}
+	}
+}
+
+// flushAll is a no-op once stop has been called, for the same reason as
+// enqueue: sending on aw.flush after stop has torn down the worker
+// goroutines that read it would block forever.
+func (a *asyncState) flushAll() {
+	a.closeMu.RLock()
+	defer a.closeMu.RUnlock()
+	if a.closed {
+		return
+	}
+
+	for _, aw := range a.workers {
+		ack := make(chan struct{})
+		aw.flush <- ack
+		<-ack
+	}
+}
+
+// stop drains and shuts down every worker. Taking closeMu for writing
+// blocks until any enqueue/flushAll call already in flight finishes, so
+// it is safe to close(aw.records) right after: no send can still be
+// racing it. Later calls see a.closed and return immediately, making
+// stop (and Close, which calls it) safe to call more than once.
+func (a *asyncState) stop() {
+	a.closeMu.Lock()
+	defer a.closeMu.Unlock()
+	if a.closed {
+		return
+	}
+	a.closed = true
+
+	for _, aw := range a.workers {
+		close(aw.records)
+	}
+	for _, aw := range a.workers {
+		<-aw.done
+	}
+}
+
+/*
+Async switches Object to asynchronous mode: every writer in the current
+Writers gets its own buffered channel (capacity bufSize) and background
+goroutine, so PrintMsg/Debug/Info/... no longer block on a slow sink.
+Records are batched and flushed to the underlying Writer at least every
+flushInterval (and sooner if a burst fills an internal batch buffer).
+
+When a writer's channel is full, OverflowPolicy decides what happens to
+the next record: Block (the default) waits for room, DropNewest discards
+the new record, DropOldest discards the oldest queued one. Dropped
+records and write errors are counted in Stats().
+
+Changing Writers after calling Async has no effect on the running
+workers; call Async again (after Close) to pick up a new Writers list.
+
+Example:
+      log.OverflowPolicy = msg.DropOldest
+      log.Async(1024, time.Second)
+      defer log.Close() // drains every writer before returning
+*/
+func (o *Object) Async(bufSize int, flushInterval time.Duration) {
+	if o.asyncRef == nil {
+		o.asyncRef = new(atomic.Pointer[asyncState])
+	}
+	if prev := o.asyncRef.Swap(nil); prev != nil {
+		prev.stop()
+	}
+	o.asyncRef.Store(newAsyncState(o.Writers, bufSize, flushInterval, o.OverflowPolicy, o.mu))
+}
+
+/*
+Flush blocks until every Async writer has drained its buffered records
+to the underlying Writer. It is a no-op in synchronous mode.
+*/
+func (o Object) Flush() {
+	if o.asyncRef == nil {
+		return
+	}
+	if async := o.asyncRef.Load(); async != nil {
+		async.flushAll()
+	}
+}
+
+// Stats returns the current Async counters. It is the zero Stats in
+// synchronous mode.
+func (o Object) Stats() Stats {
+	if o.asyncRef == nil {
+		return Stats{}
+	}
+	async := o.asyncRef.Load()
+	if async == nil {
+		return Stats{}
+	}
+	return Stats{
+		Dropped:     atomic.LoadUint64(&async.dropped),
+		WriteErrors: atomic.LoadUint64(&async.writeErrors),
+	}
+}
+
+/*
+encodeJSON renders one JSON object for a message: time, level, caller
+and msg, plus the merged key/value pairs from kv.
+*/
+func (o Object) encodeJSON(t string, m map[string]string, kv []interface{}) string {
+	rec := map[string]interface{}{
+		"time":   m["time"],
+		"level":  t,
+		"caller": fmt.Sprintf("%v:%v", m["file"], m["line"]),
+		"msg":    m["msg"],
+	}
+	for i := 0; i < len(kv); i += 2 {
+		k := fmt.Sprintf("%v", kv[i])
+		if i+1 < len(kv) {
+			rec[k] = kv[i+1]
+		} else {
+			rec[k] = nil
+		}
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Sprintf(`{"time":%q,"level":"ERROR","msg":"json encode failed: %v"}`, m["time"], err)
+	}
+	return string(b)
+}
+
 /*
 ParseFormatString transforms a format template to a format string
 and the list of fields to print in each message.
@@ -407,7 +1201,7 @@ Here is an example transformation:
 */
 func ParseFormatString(input string) (ofmt string, oids []string, err error) {
 	ofmtb := []byte{}
-	valid := []string{"file", "func", "line", "msg", "pkg", "time", "type", "utc"}
+	valid := []string{"fields", "file", "func", "line", "msg", "pkg", "time", "type", "utc"}
 	ics := []byte("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-$")
 
 	// Define the parse states.
@@ -0,0 +1,461 @@
+/*
+Package sinks provides io.WriteCloser implementations meant to be added to
+an msg.Object's Writers slice: a size/age rotating file, a syslog sink and
+a reconnecting TCP sink. Each one is safe for concurrent Write calls, so
+it works with msg.Object's own internal mutex or with the Async mode.
+
+Here is an example use.
+
+    import (
+      "jlinoff/utils/msg"
+      "jlinoff/utils/msg/sinks"
+      "io"
+      "os"
+    )
+
+    func init() {
+      rot := sinks.NewRotatingFile("/var/log/myapp/myapp.log", sinks.RotateOpts{
+        MaxSize:    10 * 1024 * 1024,
+        MaxBackups: 5,
+        MaxAge:     7 * 24 * time.Hour,
+        Compress:   true,
+      })
+      w := []io.Writer{os.Stdout, rot}
+      log, _ := msg.NewMsg("MyApp", "", "", w)
+      defer log.Close() // closes rot too
+    }
+*/
+package sinks
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log/syslog"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RotateOpts configures NewRotatingFile.
+type RotateOpts struct {
+	// MaxSize is the size, in bytes, at which the file is rotated. 0
+	// disables size-based rotation.
+	MaxSize int64
+
+	// Daily, if true, also rotates the file at the first write after local
+	// midnight, even if MaxSize has not been reached.
+	Daily bool
+
+	// MaxBackups is the number of rotated files to keep. 0 means keep all
+	// of them.
+	MaxBackups int
+
+	// MaxAge prunes rotated files older than this duration. 0 disables
+	// age-based pruning.
+	MaxAge time.Duration
+
+	// Compress gzips rotated files (but never the currently active file).
+	Compress bool
+}
+
+// rotatingFile is the io.WriteCloser returned by NewRotatingFile. The
+// file is opened lazily, on the first Write, so construction cannot
+// fail.
+type rotatingFile struct {
+	mu   sync.Mutex
+	path string
+	opts RotateOpts
+	f    *os.File
+	size int64
+	day  int
+}
+
+/*
+NewRotatingFile returns an io.WriteCloser that appends to path, rotating
+it out to path.<timestamp> (optionally gzipped) once opts.MaxSize is
+exceeded or, if opts.Daily is set, at the first write past local
+midnight. MaxBackups/MaxAge prune old rotated files after each rotation.
+
+The file is not opened until the first Write, so this never fails; open
+errors are returned from Write instead.
+*/
+func NewRotatingFile(path string, opts RotateOpts) io.WriteCloser {
+	return &rotatingFile{path: path, opts: opts}
+}
+
+func (r *rotatingFile) Write(p []byte) (n int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.f == nil {
+		if err = r.open(); err != nil {
+			return 0, err
+		}
+	}
+	if r.needsRotation(len(p)) {
+		if err = r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err = r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f = f
+	r.size = st.Size()
+	r.day = yday(st.ModTime())
+	return nil
+}
+
+func (r *rotatingFile) needsRotation(add int) bool {
+	if r.opts.MaxSize > 0 && r.size+int64(add) > r.opts.MaxSize {
+		return true
+	}
+	if r.opts.Daily && yday(time.Now()) != r.day {
+		return true
+	}
+	return false
+}
+
+func yday(t time.Time) int {
+	return t.Year()*1000 + t.YearDay()
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	r.f = nil
+
+	backup := fmt.Sprintf("%v.%v", r.path, time.Now().Format("20060102T150405.000"))
+	if err := os.Rename(r.path, backup); err != nil {
+		return err
+	}
+	if r.opts.Compress {
+		if err := compressFile(backup); err != nil {
+			return err
+		}
+		backup += ".gz"
+	}
+
+	r.prune()
+	return r.open()
+}
+
+func compressFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(path + ".gz")
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		gz.Close()
+		out.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// prune removes rotated backups beyond MaxBackups and older than MaxAge.
+// Errors removing individual files are ignored; a best-effort cleanup
+// should not stop logging.
+func (r *rotatingFile) prune() {
+	if r.opts.MaxBackups <= 0 && r.opts.MaxAge <= 0 {
+		return
+	}
+	dir := filepath.Dir(r.path)
+	base := filepath.Base(r.path)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	var backups []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name := e.Name()
+		if len(name) > len(base) && name[:len(base)+1] == base+"." {
+			backups = append(backups, filepath.Join(dir, name))
+		}
+	}
+	sort.Strings(backups) // timestamp suffix sorts chronologically
+
+	if r.opts.MaxAge > 0 {
+		cutoff := time.Now().Add(-r.opts.MaxAge)
+		kept := backups[:0]
+		for _, b := range backups {
+			if st, err := os.Stat(b); err == nil && st.ModTime().Before(cutoff) {
+				os.Remove(b)
+				continue
+			}
+			kept = append(kept, b)
+		}
+		backups = kept
+	}
+
+	if r.opts.MaxBackups > 0 && len(backups) > r.opts.MaxBackups {
+		for _, b := range backups[:len(backups)-r.opts.MaxBackups] {
+			os.Remove(b)
+		}
+	}
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
+
+/*
+NewSyslog returns an io.WriteCloser that ships to a syslog daemon via
+network/addr (e.g. "udp"/"localhost:514", or ""/"" for the local
+syslogd), tagged tag and logged at priority. The connection is dialed
+lazily on the first Write so construction cannot fail.
+*/
+func NewSyslog(network, addr, tag string, priority syslog.Priority) io.WriteCloser {
+	return &syslogSink{network: network, addr: addr, tag: tag, priority: priority}
+}
+
+type syslogSink struct {
+	network, addr, tag string
+	priority           syslog.Priority
+
+	mu sync.Mutex
+	w  *syslog.Writer
+}
+
+func (s *syslogSink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.w == nil {
+		w, err := syslog.Dial(s.network, s.addr, s.priority, s.tag)
+		if err != nil {
+			return 0, err
+		}
+		s.w = w
+	}
+	return s.w.Write(p)
+}
+
+func (s *syslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.w == nil {
+		return nil
+	}
+	err := s.w.Close()
+	s.w = nil
+	return err
+}
+
+// BackoffPolicy controls how NewTCPSink retries a dial after a failed
+// connection attempt.
+type BackoffPolicy struct {
+	// Initial is the delay before the first retry. Defaults to 100ms if
+	// zero.
+	Initial time.Duration
+
+	// Max caps the delay between retries. Defaults to 30s if zero.
+	Max time.Duration
+
+	// Multiplier grows the delay after each retry. Defaults to 2 if <= 1.
+	Multiplier float64
+
+	// MaxRetries bounds the number of retries after the first failed dial
+	// in each background reconnect run, so at most MaxRetries+1 dial
+	// attempts are made before that run gives up. 0 means retry forever.
+	MaxRetries int
+}
+
+// DefaultBackoff returns a reasonable BackoffPolicy for the background
+// reconnect loop NewTCPSink runs: 100ms initial delay, doubling up to a
+// 30s cap, retried indefinitely. Because reconnecting now happens off
+// the Write path (see NewTCPSink), an unbounded MaxRetries here no
+// longer risks blocking a caller forever.
+func DefaultBackoff() BackoffPolicy {
+	return BackoffPolicy{Initial: 100 * time.Millisecond, Max: 30 * time.Second, Multiplier: 2}
+}
+
+/*
+NewTCPSink returns an io.WriteCloser that ships lines to a remote log
+collector over TCP, reconnecting according to reconnect when the
+connection drops or was never established.
+
+Write never blocks on dialing or retrying: if there is no live
+connection it kicks off (at most one) background reconnect goroutine and
+drops that write, silently and without error (see Write). This matters
+because Write is called from msg.Object.output() while holding its own
+mutex (see the doc comment of msg.Object's mu field) - a Write that blocked retrying a dead
+collector would freeze every goroutine logging through that Object, not
+just this sink, and a Write that returned an error for every drop would
+make a transient outage fatal to the whole process instead.
+*/
+func NewTCPSink(addr string, reconnect BackoffPolicy) io.WriteCloser {
+	return &tcpSink{addr: addr, backoff: reconnect, closedCh: make(chan struct{})}
+}
+
+type tcpSink struct {
+	addr    string
+	backoff BackoffPolicy
+
+	mu           sync.Mutex
+	conn         net.Conn
+	reconnecting bool
+	closed       bool
+	closedCh     chan struct{}
+}
+
+// Write never blocks and, other than after Close, never returns an error:
+// while the collector is unreachable it drops p and leaves reconnectLoop
+// to restore the connection in the background. A sink that returned an
+// error here for every drop would make every transient network hiccup
+// fatal to the whole process, since msg.Object.output treats any writer
+// error as fatal - so, like a UDP-based log shipper, this sink trades
+// delivery guarantees for never taking the caller down with it.
+func (t *tcpSink) Write(p []byte) (n int, err error) {
+	t.mu.Lock()
+	conn := t.conn
+	closed := t.closed
+	t.mu.Unlock()
+
+	if closed {
+		return 0, fmt.Errorf("sinks: tcp sink to %v is closed", t.addr)
+	}
+	if conn == nil {
+		t.startReconnecting()
+		return len(p), nil
+	}
+
+	n, werr := conn.Write(p)
+	if werr != nil {
+		t.mu.Lock()
+		if t.conn == conn {
+			t.conn = nil
+		}
+		t.mu.Unlock()
+		conn.Close()
+		t.startReconnecting()
+		return len(p), nil
+	}
+	return n, nil
+}
+
+// startReconnecting launches the background dial loop if one is not
+// already running. It never blocks the caller.
+func (t *tcpSink) startReconnecting() {
+	t.mu.Lock()
+	if t.closed || t.reconnecting {
+		t.mu.Unlock()
+		return
+	}
+	t.reconnecting = true
+	t.mu.Unlock()
+
+	go t.reconnectLoop()
+}
+
+// reconnectLoop dials t.addr, retrying with backoff, until it succeeds,
+// the sink is closed, or BackoffPolicy.MaxRetries (if non-zero) is used
+// up; a later Write starts a new run. It runs entirely off the Write path.
+func (t *tcpSink) reconnectLoop() {
+	defer func() {
+		t.mu.Lock()
+		t.reconnecting = false
+		t.mu.Unlock()
+	}()
+
+	initial := t.backoff.Initial
+	if initial <= 0 {
+		initial = 100 * time.Millisecond
+	}
+	max := t.backoff.Max
+	if max <= 0 {
+		max = 30 * time.Second
+	}
+	mult := t.backoff.Multiplier
+	if mult <= 1 {
+		mult = 2
+	}
+
+	delay := initial
+	for attempt := 0; t.backoff.MaxRetries == 0 || attempt <= t.backoff.MaxRetries; attempt++ {
+		conn, err := net.DialTimeout("tcp", t.addr, initial)
+		if err == nil {
+			t.mu.Lock()
+			if t.closed {
+				t.mu.Unlock()
+				conn.Close()
+				return
+			}
+			t.conn = conn
+			t.mu.Unlock()
+			return
+		}
+		if t.backoff.MaxRetries != 0 && attempt == t.backoff.MaxRetries {
+			return
+		}
+		select {
+		case <-t.closedCh:
+			return
+		case <-time.After(delay):
+		}
+		delay = time.Duration(float64(delay) * mult)
+		if delay > max {
+			delay = max
+		}
+	}
+}
+
+func (t *tcpSink) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	conn := t.conn
+	t.conn = nil
+	t.mu.Unlock()
+
+	close(t.closedCh)
+	if conn == nil {
+		return nil
+	}
+	return conn.Close()
+}
@@ -37,14 +37,31 @@ Here is an example usage.
 package run
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"sync"
 	"syscall"
+	"time"
 )
 
+// termGrace is how long CmdCtx waits after sending SIGTERM for the
+// process to exit on its own before escalating to SIGKILL.
+const termGrace = 5 * time.Second
+
+// maxScanTokenSize bounds how long a single line of stdout/stderr can be
+// before CmdCtx gives up on it. bufio.Scanner's own default (64KB) is
+// easily exceeded by real output (minified JS, base64, a progress bar
+// redrawing one long line), and Scan() fails silently unless its error
+// is checked - so this is both larger and, unlike the default, actually
+// surfaced via scanner.Err() below.
+const maxScanTokenSize = 16 * 1024 * 1024
+
 /*
 Cmd runs a command.
 
@@ -60,20 +77,11 @@ func Cmd(a []string) (output string, err error) {
 		return
 	}
 
-	// Create the command object.
-	c := exec.Command(a[0], a[1:]...)
-
-	// Write stdout and stderr to a buffer and to os.Stdout.
-	var buf bytes.Buffer
-	writers := []io.Writer{&buf, os.Stdout}
-	w := io.MultiWriter(writers...)
-	c.Stdout = w
-	c.Stderr = w
-	c.Stdin = os.Stdin
-
-	// Run the command.
-	err = c.Run()
-	output = buf.String()
+	res, e := CmdCtx(context.Background(), a, Options{Stdin: os.Stdin, Writers: []io.Writer{os.Stdout}})
+	err = e
+	if res != nil {
+		output = res.Combined
+	}
 	return
 }
 
@@ -100,17 +108,7 @@ func CmdWithWriters(a []string, w []io.Writer) (err error) {
 		return
 	}
 
-	// Create the command object.
-	c := exec.Command(a[0], a[1:]...)
-
-	// Write stdout and stderr to a buffer and to os.Stdout.
-	m := io.MultiWriter(w...)
-	c.Stdout = m
-	c.Stderr = m
-	c.Stdin = os.Stdin
-
-	// Run the command.
-	err = c.Run()
+	_, err = CmdCtx(context.Background(), a, Options{Stdin: os.Stdin, Writers: w})
 	return
 }
 
@@ -131,14 +129,230 @@ func CmdSilent(a []string) (output string, err error) {
 		return
 	}
 
-	// Create the command object.
-	c := exec.Command(a[0], a[1:]...)
-
-	// Run the command silently.
-	out, e := c.CombinedOutput()
+	res, e := CmdCtx(context.Background(), a, Options{})
 	err = e
-	output = string(out)
+	if res != nil {
+		output = res.Combined
+	}
+	return
+}
+
+// Options configures CmdCtx.
+type Options struct {
+	// Stdin, if set, is connected to the child's standard input.
+	Stdin io.Reader
+
+	// Env, if non-empty, replaces the child's environment (just like
+	// exec.Cmd.Env). If empty, the child inherits this process's
+	// environment.
+	Env []string
+
+	// Dir, if set, is the child's working directory.
+	Dir string
+
+	// Timeout, if > 0, bounds how long the command may run. On expiry the
+	// child is sent SIGTERM and, if it has not exited after a grace
+	// period, SIGKILL. Result.TimedOut is set and the returned error wraps
+	// context.DeadlineExceeded.
+	Timeout time.Duration
+
+	// OnStdout, if set, is called with each line of stdout as it is read.
+	OnStdout func(line string)
+
+	// OnStderr, if set, is called with each line of stderr as it is read.
+	OnStderr func(line string)
+
+	// Writers, if set, receive every stdout/stderr line (with its
+	// trailing newline restored) as it is read, in addition to the
+	// Stdout/Stderr/Combined strings returned in Result.
+	Writers []io.Writer
+}
+
+// Result is what CmdCtx returns.
+type Result struct {
+	// Stdout is everything the child wrote to stdout.
+	Stdout string
+
+	// Stderr is everything the child wrote to stderr.
+	Stderr string
+
+	// Combined is stdout and stderr interleaved in the order each line was
+	// read.
+	Combined string
+
+	// ExitCode is the child's exit code, see GetExitCode.
+	ExitCode int
+
+	// Duration is how long the command ran.
+	Duration time.Duration
+
+	// TimedOut is true if Options.Timeout (or a deadline inherited from
+	// ctx) elapsed before the child exited on its own. It is false if ctx
+	// was canceled directly instead - see the returned error, which wraps
+	// context.Canceled in that case rather than context.DeadlineExceeded.
+	TimedOut bool
+}
+
+/*
+CmdCtx runs a command with streaming per-line callbacks and cancellation.
+
+Stdout and stderr are scanned line by line in separate goroutines; each
+line is handed to Options.OnStdout/OnStderr as it arrives and fanned out
+to Options.Writers, while Result accumulates the full Stdout, Stderr and
+Combined text for callers that just want it all at the end.
+
+If ctx is cancelled, or Options.Timeout elapses, the child is sent
+SIGTERM; if it has not exited termGrace later it is sent SIGKILL. Only a
+timeout (Options.Timeout or a deadline inherited from ctx) sets
+Result.TimedOut and wraps the returned error in context.DeadlineExceeded;
+a direct ctx cancellation wraps it in context.Canceled instead (see
+GetExitCode).
+
+Example:
+      ctx, cancel := context.WithCancel(context.Background())
+      defer cancel()
+      res, e := run.CmdCtx(ctx, strings.Fields("ls -l"), run.Options{
+          Timeout:  30 * time.Second,
+          OnStdout: func(line string) { fmt.Println("out:", line) },
+      })
+      if e != nil { panic(e) }
+      fmt.Printf("exit = %v, took %v\n", res.ExitCode, res.Duration)
+*/
+func CmdCtx(ctx context.Context, args []string, opts Options) (result *Result, err error) {
+	start := time.Now()
+	result = &Result{}
 
+	if len(args) == 0 {
+		err = fmt.Errorf("no command specified")
+		return
+	}
+
+	runCtx := ctx
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	c := exec.Command(args[0], args[1:]...)
+	c.Dir = opts.Dir
+	if len(opts.Env) > 0 {
+		c.Env = opts.Env
+	}
+	if opts.Stdin != nil {
+		c.Stdin = opts.Stdin
+	}
+
+	stdoutPipe, e := c.StdoutPipe()
+	if e != nil {
+		err = e
+		return
+	}
+	stderrPipe, e := c.StderrPipe()
+	if e != nil {
+		err = e
+		return
+	}
+
+	if e := c.Start(); e != nil {
+		err = e
+		return
+	}
+
+	// mu guards the three buffers and the fan-out to Writers so the
+	// stdout and stderr scanning goroutines below do not interleave
+	// writes.
+	var mu sync.Mutex
+	var stdoutBuf, stderrBuf, combinedBuf bytes.Buffer
+	appendLine := func(buf *bytes.Buffer, line string) {
+		mu.Lock()
+		defer mu.Unlock()
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+		combinedBuf.WriteString(line)
+		combinedBuf.WriteByte('\n')
+		for _, w := range opts.Writers {
+			fmt.Fprintln(w, line)
+		}
+	}
+
+	var stdoutErr, stderrErr error
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		scanner := bufio.NewScanner(stdoutPipe)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxScanTokenSize)
+		for scanner.Scan() {
+			line := scanner.Text()
+			appendLine(&stdoutBuf, line)
+			if opts.OnStdout != nil {
+				opts.OnStdout(line)
+			}
+		}
+		stdoutErr = scanner.Err()
+	}()
+	go func() {
+		defer wg.Done()
+		scanner := bufio.NewScanner(stderrPipe)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxScanTokenSize)
+		for scanner.Scan() {
+			line := scanner.Text()
+			appendLine(&stderrBuf, line)
+			if opts.OnStderr != nil {
+				opts.OnStderr(line)
+			}
+		}
+		stderrErr = scanner.Err()
+	}()
+
+	// readersDone fires once both scanners have hit EOF, which happens
+	// once the child (or, on timeout, SIGTERM/SIGKILL) closes its stdout
+	// and stderr. cmd.Wait() below must not run until then: Wait() closes
+	// the pipes as soon as it reaps the process, and doing that while the
+	// scanners are still draining buffered output would truncate it.
+	readersDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(readersDone)
+	}()
+
+	select {
+	case <-readersDone:
+	case <-runCtx.Done():
+		// runCtx.Done() fires both when opts.Timeout (or an inherited
+		// deadline) elapses and when the caller cancels ctx directly;
+		// runCtx.Err() tells us which. Only the former is a timeout -
+		// TimedOut and the context.DeadlineExceeded wrapping below are
+		// reserved for it, so a caller that cancels its own context sees
+		// context.Canceled instead of a misleading "timed out".
+		result.TimedOut = errors.Is(runCtx.Err(), context.DeadlineExceeded)
+		c.Process.Signal(syscall.SIGTERM)
+		select {
+		case <-readersDone:
+		case <-time.After(termGrace):
+			c.Process.Kill()
+			<-readersDone
+		}
+	}
+
+	err = c.Wait()
+
+	// errors.Join, not fmt.Errorf("%w: %v", ...), so that both ctxErr and
+	// the underlying *exec.ExitError (from a SIGTERM/SIGKILL'd process)
+	// stay discoverable via errors.Is/errors.As - GetExitCode relies on
+	// being able to find the ExitError even when ctx was cancelled.
+	err = errors.Join(runCtx.Err(), err)
+
+	// Surface a truncated/over-long line rather than silently dropping
+	// the rest of that stream, as the bufio.Scanner default would.
+	err = errors.Join(err, stdoutErr, stderrErr)
+
+	result.Stdout = stdoutBuf.String()
+	result.Stderr = stderrBuf.String()
+	result.Combined = combinedBuf.String()
+	result.ExitCode = GetExitCode(err)
+	result.Duration = time.Since(start)
 	return
 }
 
@@ -154,11 +368,24 @@ Here is how you might use it.
         code = GetExitCode(e)
         fmt.Printf("exit code %v", code)
       }
+
+If err wraps context.DeadlineExceeded (as CmdCtx returns when Options.Timeout
+or the context expires), code is -1, since a killed process has no
+meaningful exit status.
+
+err is unwrapped with errors.As to find the *exec.ExitError rather than
+asserted directly, since CmdCtx may return it joined (via errors.Join)
+with a stdout/stderr scan error.
 */
 func GetExitCode(err error) (code int) {
 	code = 0
 	if err != nil {
-		if exiterr, ok := err.(*exec.ExitError); ok {
+		if errors.Is(err, context.DeadlineExceeded) {
+			code = -1
+			return
+		}
+		var exiterr *exec.ExitError
+		if errors.As(err, &exiterr) {
 			if status, ok := exiterr.Sys().(syscall.WaitStatus); ok {
 				code = status.ExitStatus()
 			} else {
@@ -4,10 +4,15 @@ The tester tool verifies that the go utils package are working as expected.
 package main
 
 import (
+	"context"
 	"io"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 	"github.com/jlinoff/go/msg"
+	"github.com/jlinoff/go/msg/sinks"
 	"github.com/jlinoff/go/run"
 )
 
@@ -15,7 +20,13 @@ var log *msg.Object
 
 func main() {
 	testMsg()
+	testWith()
+	testAsync()
+	testVerbosity()
+	testFilters()
+	testSinks()
 	testRun()
+	testCmdCtxTimeout()
 	log.Info("success")
 }
 
@@ -46,6 +57,105 @@ func testMsg() {
 	log.Printf("any old random stuff\n")
 }
 
+func testWith() {
+	log.Info("testing With() and Debugw()")
+	child := log.With("request_id", 42, "user", "alice")
+	child.Debugw("handling request", "path", "/health")
+	child.Infow("request done", "status", 200)
+}
+
+func testAsync() {
+	log.Info("testing Async() and Close()")
+	var buf strings.Builder
+	alog, e := msg.NewMsg("AsyncTester", "", "", []io.Writer{&buf})
+	if e != nil {
+		panic(e)
+	}
+	alog.Async(64, 10*time.Millisecond)
+	for i := 0; i < 100; i++ {
+		alog.Info("async message %v", i)
+	}
+	alog.Flush()
+	if e := alog.Close(); e != nil {
+		panic(e)
+	}
+	log.Info("async buffered %v bytes", buf.Len())
+}
+
+func testVerbosity() {
+	log.Info("testing V()/Verbosity/VModule")
+	log.Verbosity = 1
+	if !log.V(1) {
+		panic("expected V(1) to be enabled at Verbosity=1")
+	}
+	if log.V(2) {
+		panic("expected V(2) to be disabled at Verbosity=1")
+	}
+
+	log.VModule = "tester=2"
+	if !log.V(2) {
+		panic("expected VModule=\"tester=2\" to enable V(2) for this file")
+	}
+	log.VModule = ""
+	log.Verbosity = 0
+}
+
+func testFilters() {
+	log.Info("testing AddFilter and the built-in filters")
+	var buf strings.Builder
+	flog, e := msg.NewMsg("FilterTester", "", "", []io.Writer{&buf})
+	if e != nil {
+		panic(e)
+	}
+	flog.AddFilter(msg.FilterKeys("password"))
+	flog.AddFilter(msg.FilterValues("secret123"))
+	flog.AddFilter(msg.FilterRegex(regexp.MustCompile(`\d{4}-\d{4}-\d{4}-\d{4}`)))
+	flog.AddFilter(msg.FilterMinLevel(msg.WARNING))
+
+	flog.Infow("this should be dropped by FilterMinLevel", "password", "hunter2")
+	flog.Warnw("card 1234-5678-9012-3456 charged", "password", "hunter2", "note", "secret123")
+
+	out := buf.String()
+	if strings.Contains(out, "hunter2") || strings.Contains(out, "1234-5678-9012-3456") || strings.Contains(out, "secret123") {
+		panic("expected filters to redact sensitive values: " + out)
+	}
+	if strings.Contains(out, "dropped by FilterMinLevel") {
+		panic("expected FilterMinLevel to drop the Info call")
+	}
+	log.Info("filtered output = %v", strings.TrimSpace(out))
+}
+
+func testSinks() {
+	log.Info("testing msg/sinks.NewRotatingFile")
+	dir, e := os.MkdirTemp("", "tester-sinks")
+	if e != nil {
+		panic(e)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+	rot := sinks.NewRotatingFile(path, sinks.RotateOpts{MaxSize: 64, MaxBackups: 2})
+	slog, e := msg.NewMsg("SinkTester", "", "", []io.Writer{rot})
+	if e != nil {
+		panic(e)
+	}
+	for i := 0; i < 20; i++ {
+		slog.Info("sink message %v, padding to force rotation", i)
+	}
+	if e := slog.Close(); e != nil {
+		panic(e)
+	}
+
+	backups, e := filepath.Glob(path + ".*")
+	if e != nil {
+		panic(e)
+	}
+	log.Info("rotated backups = %v", len(backups))
+	if len(backups) == 0 {
+		panic("expected at least one rotated backup file")
+	}
+}
+
 func testRun() {
 	log.Info("testing the run.Cmd() function")
 	cmd := "./genout.sh 10 72"
@@ -68,6 +178,17 @@ func testRun() {
 	}
 }
 
+func testCmdCtxTimeout() {
+	log.Info("testing run.CmdCtx() with a timeout")
+	res, e := run.CmdCtx(context.Background(), []string{"sleep", "5"}, run.Options{Timeout: 100 * time.Millisecond})
+	log.Info("timedOut = %v", res.TimedOut)
+	log.Info("exitCode = %v", run.GetExitCode(e))
+	log.Info("err = %v", e)
+	if !res.TimedOut {
+		panic("expected CmdCtx to time out")
+	}
+}
+
 func init() {
 	n := "Tester"
 	f := `%pkg %(-27)time %(-7)type %file %line - %msg`